@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"goroutines_pipeline/pipeline"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Addressing selects how saveImage names files it writes to disk.
+type Addressing int
+
+const (
+	// AddressByPath mirrors the input's relative path under outputDir, the
+	// original behavior.
+	AddressByPath Addressing = iota
+	// AddressByHash names each file after the SHA-256 of its encoded
+	// bytes, the scheme used by the arrange tool: reprocessing the same
+	// source image produces the same output path instead of a duplicate.
+	AddressByHash
+)
+
+// parseAddressing parses the -addressing flag value.
+func parseAddressing(s string) (Addressing, error) {
+	switch s {
+	case "", "path":
+		return AddressByPath, nil
+	case "hash":
+		return AddressByHash, nil
+	default:
+		return AddressByPath, fmt.Errorf("unknown addressing %q: want \"path\" or \"hash\"", s)
+	}
+}
+
+// createHashBuckets pre-creates the 256 two-hex-digit bucket directories
+// hash addressing writes into, so saveImage's workers never race each
+// other to create the same bucket.
+func createHashBuckets(outputDir string) error {
+	for i := 0; i < 256; i++ {
+		bucket := filepath.Join(outputDir, fmt.Sprintf("%02x", i))
+		if err := os.MkdirAll(bucket, 0o755); err != nil {
+			return fmt.Errorf("creating hash bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+func extensionFor(output pipeline.OutputConfig) string {
+	if strings.ToLower(output.Format) == "png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// writeJobImage encodes job.Image and writes it to disk, returning the path
+// it was written to and, under hash addressing, the hash that path is
+// derived from.
+func writeJobImage(job Job, outputDir string, output pipeline.OutputConfig, addressing Addressing) (outPath, hash string, err error) {
+	if addressing != AddressByHash {
+		outPath = job.OutPath
+		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+			return "", "", fmt.Errorf("creating output dir for %s: %w", outPath, err)
+		}
+		f, err := os.Create(outPath)
+		if err != nil {
+			return "", "", fmt.Errorf("creating %s: %w", outPath, err)
+		}
+		defer f.Close()
+		return outPath, "", pipeline.Encode(f, job.Image, output)
+	}
+
+	var buf bytes.Buffer
+	if err := pipeline.Encode(&buf, job.Image, output); err != nil {
+		return "", "", fmt.Errorf("encoding %s: %w", job.InputPath, err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	hash = hex.EncodeToString(sum[:])
+	outPath = filepath.Join(outputDir, hash[:2], hash+extensionFor(output))
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return "", "", fmt.Errorf("creating %s: %w", outPath, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return "", "", fmt.Errorf("writing %s: %w", outPath, err)
+	}
+	return outPath, hash, nil
+}
+
+// manifestWriter appends "input_path,output_hash" lines recording which
+// hash each source file produced, the mapping hash addressing otherwise
+// discards by naming files after content rather than origin.
+type manifestWriter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newManifestWriter(outputDir string) (*manifestWriter, error) {
+	f, err := os.OpenFile(filepath.Join(outputDir, "manifest.csv"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening manifest: %w", err)
+	}
+	return &manifestWriter{file: f}, nil
+}
+
+func (m *manifestWriter) record(inputPath, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.file, "%s,%s\n", inputPath, hash)
+	return err
+}
+
+func (m *manifestWriter) Close() error {
+	return m.file.Close()
+}
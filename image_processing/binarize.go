@@ -0,0 +1,171 @@
+// Package imageprocessing implements the pipeline's core image operations
+// (load, resize, grayscale, write, and - here - adaptive binarization).
+package imageprocessing
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Binarize applies Sauvola's adaptive threshold to img (converting it to
+// grayscale first if it isn't already) and returns a *image.Gray suitable
+// for the existing WriteImage path. w is the local window size (Sauvola's
+// default of 19), k is the sensitivity constant (0.3), and R is the
+// dynamic range of the standard deviation (128 for 8-bit images).
+//
+// At each pixel the threshold is T = m*(1 + k*(s/R - 1)), where m and s are
+// the local mean and standard deviation over a w x w window, computed in
+// O(1) per pixel from integral images of the pixel values and their
+// squares. Windows are clamped at the border rather than padded, so edge
+// pixels are thresholded against a smaller, still-real window. Images only
+// 1 pixel tall or wide have no meaningful local window and fall back to a
+// single global Otsu threshold.
+func Binarize(img image.Image, w int, k, R float64) *image.Gray {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width <= 1 || height <= 1 {
+		return otsuBinarize(gray)
+	}
+
+	sum, sqSum := buildIntegralImages(gray)
+	half := w / 2
+
+	out := image.NewGray(bounds)
+	for y := 0; y < height; y++ {
+		y0 := clamp(y-half, 0, height-1)
+		y1 := clamp(y+half, 0, height-1)
+		for x := 0; x < width; x++ {
+			x0 := clamp(x-half, 0, width-1)
+			x1 := clamp(x+half, 0, width-1)
+
+			n := float64((x1 - x0 + 1) * (y1 - y0 + 1))
+			s := windowSum(sum, x0, y0, x1, y1)
+			sq := windowSum(sqSum, x0, y0, x1, y1)
+
+			mean := s / n
+			variance := sq/n - mean*mean
+			if variance < 0 {
+				variance = 0
+			}
+			stddev := math.Sqrt(variance)
+
+			threshold := mean * (1 + k*(stddev/R-1))
+
+			pixel := gray.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y
+			value := uint8(0)
+			if float64(pixel) >= threshold {
+				value = 255
+			}
+			out.SetGray(bounds.Min.X+x, bounds.Min.Y+y, color.Gray{Y: value})
+		}
+	}
+
+	return out
+}
+
+func toGray(img image.Image) *image.Gray {
+	if g, ok := img.(*image.Gray); ok {
+		return g
+	}
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+	return gray
+}
+
+// buildIntegralImages returns (width+1) x (height+1) integral images of
+// gray's pixel values and their squares, so any rectangular window's sum
+// and sum-of-squares can be read in O(1) via windowSum.
+func buildIntegralImages(gray *image.Gray) (sum, sqSum [][]float64) {
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	sum = make([][]float64, height+1)
+	sqSum = make([][]float64, height+1)
+	for y := range sum {
+		sum[y] = make([]float64, width+1)
+		sqSum[y] = make([]float64, width+1)
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := float64(gray.GrayAt(bounds.Min.X+x, bounds.Min.Y+y).Y)
+			sum[y+1][x+1] = v + sum[y][x+1] + sum[y+1][x] - sum[y][x]
+			sqSum[y+1][x+1] = v*v + sqSum[y][x+1] + sqSum[y+1][x] - sqSum[y][x]
+		}
+	}
+	return sum, sqSum
+}
+
+// windowSum reads the sum over [x0,x1] x [y0,y1] (inclusive) from an
+// integral image built by buildIntegralImages.
+func windowSum(integral [][]float64, x0, y0, x1, y1 int) float64 {
+	return integral[y1+1][x1+1] - integral[y0][x1+1] - integral[y1+1][x0] + integral[y0][x0]
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// otsuBinarize is the fallback for images with no meaningful local window:
+// a single global threshold chosen by Otsu's method (maximizing
+// between-class variance over the pixel histogram).
+func otsuBinarize(gray *image.Gray) *image.Gray {
+	bounds := gray.Bounds()
+
+	var hist [256]int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			hist[gray.GrayAt(x, y).Y]++
+		}
+	}
+
+	total := bounds.Dx() * bounds.Dy()
+	var sumAll float64
+	for level, count := range hist {
+		sumAll += float64(level) * float64(count)
+	}
+
+	var sumB, weightB, best float64
+	threshold := 0
+	for level, count := range hist {
+		weightB += float64(count)
+		if weightB == 0 {
+			continue
+		}
+		weightF := float64(total) - weightB
+		if weightF == 0 {
+			break
+		}
+		sumB += float64(level) * float64(count)
+		meanB := sumB / weightB
+		meanF := (sumAll - sumB) / weightF
+		between := weightB * weightF * (meanB - meanF) * (meanB - meanF)
+		if between > best {
+			best = between
+			threshold = level
+		}
+	}
+
+	out := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			value := uint8(0)
+			if int(gray.GrayAt(x, y).Y) > threshold {
+				value = 255
+			}
+			out.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package imageprocessing
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Unit Test for Binarize on a simple checkerboard: bright squares should
+// end up white (255) and dark squares black (0).
+func TestBinarizeCheckerboard(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if (x/4+y/4)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 220})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 20})
+			}
+		}
+	}
+
+	out := Binarize(img, 19, 0.3, 128)
+
+	assert.Equal(t, uint8(255), out.GrayAt(0, 0).Y, "Expected a bright square to binarize to white")
+	assert.Equal(t, uint8(0), out.GrayAt(4, 0).Y, "Expected a dark square to binarize to black")
+}
+
+// Unit Test for Binarize falling back to Otsu on a 1-pixel-tall image.
+func TestBinarizeOnePixelTallFallsBackToOtsu(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 1))
+	img.SetGray(0, 0, color.Gray{Y: 10})
+	img.SetGray(1, 0, color.Gray{Y: 20})
+	img.SetGray(2, 0, color.Gray{Y: 230})
+	img.SetGray(3, 0, color.Gray{Y: 240})
+
+	out := Binarize(img, 19, 0.3, 128)
+
+	assert.Equal(t, uint8(0), out.GrayAt(0, 0).Y)
+	assert.Equal(t, uint8(255), out.GrayAt(3, 0).Y)
+}
@@ -0,0 +1,57 @@
+package imageprocessing
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// defaultResizeWidth mirrors the width the original hard-coded sequential
+// pipeline resized every image to, preserved here since Resize takes no
+// parameters of its own.
+const defaultResizeWidth = 800
+
+// ReadImage opens and decodes the image at path. It panics on any error,
+// matching the rest of this package: callers that need a recoverable error
+// (runSequential, loadImage) wrap it via a safe*/recoverInto helper instead
+// of checking a returned error here.
+func ReadImage(path string) image.Image {
+	f, err := os.Open(path)
+	if err != nil {
+		panic(fmt.Errorf("image_processing: reading %s: %w", path, err))
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		panic(fmt.Errorf("image_processing: decoding %s: %w", path, err))
+	}
+	return img
+}
+
+// Resize scales img to defaultResizeWidth wide, preserving aspect ratio.
+func Resize(img image.Image) image.Image {
+	return imaging.Resize(img, defaultResizeWidth, 0, imaging.Lanczos)
+}
+
+// Grayscale desaturates img.
+func Grayscale(img image.Image) image.Image {
+	return imaging.Grayscale(img)
+}
+
+// WriteImage encodes img as a JPEG and writes it to path. It panics on any
+// error, for the same reason as ReadImage.
+func WriteImage(path string, img image.Image) {
+	f, err := os.Create(path)
+	if err != nil {
+		panic(fmt.Errorf("image_processing: creating %s: %w", path, err))
+	}
+	defer f.Close()
+
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 90}); err != nil {
+		panic(fmt.Errorf("image_processing: encoding %s: %w", path, err))
+	}
+}
@@ -1,19 +1,81 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"goroutines_pipeline/image_processing"
+	"goroutines_pipeline/pipeline"
 	"image"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
-// Job structure to hold image paths and image object
+// JobResult reports the terminal outcome of a Job once it exits the
+// pipeline: which stage it failed in (if any), and how long each stage that
+// did run took.
+type JobResult struct {
+	Path      string
+	Err       error
+	Stage     string
+	Durations map[string]time.Duration
+}
+
+// Job structure to hold image paths and image object. Result mirrors
+// restic's pipe.Entry.Result: loadImage, the first stage a Job enters, points
+// it at the channel its outcome should be reported on, so every later stage can just
+// forward a failed Job along without knowing who is listening. Root is the
+// walkImages root InputPath was found under, so later stages can derive
+// OutPath relative to it instead of assuming a fixed layout.
 type Job struct {
 	InputPath string
+	Root      string
 	Image     image.Image
 	OutPath   string
+	Err       error
+	Stage     string
+	Durations map[string]time.Duration
+	Result    chan<- JobResult
+}
+
+// relativeOutputPath places a Job's processed output under outputDir,
+// mirroring the directory structure InputPath has relative to Root. This
+// keeps output paths correct for any root (not just ones containing the
+// literal substring "images/") and preserves nested subdirectories instead
+// of colliding them all into outputDir's top level. An empty Root (e.g. a
+// Job built by hand rather than by walkImages) is treated as ".", leaving
+// InputPath unchanged.
+func relativeOutputPath(job Job, outputDir string) (string, error) {
+	root := job.Root
+	if root == "" {
+		root = "."
+	}
+	rel, err := filepath.Rel(root, job.InputPath)
+	if err != nil {
+		return "", fmt.Errorf("computing output path for %s: %w", job.InputPath, err)
+	}
+	return filepath.Join(outputDir, rel), nil
+}
+
+// fail records the first error a Job hits, tagged with the stage that
+// produced it; later stages see Err set and skip their own work.
+func (j *Job) fail(stage string, err error) {
+	if j.Err == nil {
+		j.Err = err
+		j.Stage = stage
+	}
+}
+
+func (j *Job) recordDuration(stage string, d time.Duration) {
+	if j.Durations == nil {
+		j.Durations = make(map[string]time.Duration)
+	}
+	j.Durations[stage] = d
 }
 
 // Error checking: Validate image file (ensure it's a JPEG)
@@ -38,34 +100,106 @@ func directoryExists(path string) bool {
 	return info.IsDir()
 }
 
-// Sequential implementation of pipeline stages
-func runSequential(imagePaths []string, outputDir string) {
+// recoverInto turns a panic into *err, leaving err untouched if there was
+// none. It lets safeReadImage/safeResize/safeGrayscale/safeWriteImage treat
+// a panicking image_processing call the same as one that returns an error.
+func recoverInto(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic: %v", r)
+	}
+}
+
+func safeReadImage(path string) (img image.Image, err error) {
+	defer recoverInto(&err)
+	return imageprocessing.ReadImage(path), nil
+}
+
+func safeResize(img image.Image) (out image.Image, err error) {
+	defer recoverInto(&err)
+	return imageprocessing.Resize(img), nil
+}
+
+func safeGrayscale(img image.Image) (out image.Image, err error) {
+	defer recoverInto(&err)
+	return imageprocessing.Grayscale(img), nil
+}
+
+func safeWriteImage(path string, img image.Image) (err error) {
+	defer recoverInto(&err)
+	imageprocessing.WriteImage(path, img)
+	return nil
+}
+
+// Sequential implementation of pipeline stages. Unlike runParallel, it does
+// not consult a pipeline.Config: it always runs the original hard-coded
+// resize+grayscale steps, so main rejects -pipeline/-workers/-addressing
+// when the user selects sequential mode rather than silently ignoring them.
+// ctx lets a SIGINT or caller-imposed timeout stop the loop between jobs
+// instead of running the whole batch to completion.
+func runSequential(ctx context.Context, jobs <-chan Job, outputDir string) {
 	startPipeline := time.Now() // Start timer for sequential mode
 
-	for _, path := range imagePaths {
-		if err := validateImage(path); err != nil {
+loop:
+	for {
+		var job Job
+		select {
+		case j, ok := <-jobs:
+			if !ok {
+				break loop
+			}
+			job = j
+		case <-ctx.Done():
+			break loop
+		}
+
+		if err := validateImage(job.InputPath); err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		outPath, err := relativeOutputPath(job, outputDir)
+		if err != nil {
 			fmt.Println(err)
 			continue
 		}
+		job.OutPath = outPath
 
 		start := time.Now()
-		job := Job{
-			InputPath: path,
-			OutPath:   strings.Replace(path, "images/", outputDir, 1),
+		img, err := safeReadImage(job.InputPath)
+		if err != nil {
+			fmt.Println("load:", err)
+			continue
 		}
-		job.Image = imageprocessing.ReadImage(path)
+		job.Image = img
 		fmt.Printf("Load stage took %v\n", time.Since(start))
 
 		start = time.Now()
-		job.Image = imageprocessing.Resize(job.Image)
+		img, err = safeResize(job.Image)
+		if err != nil {
+			fmt.Println("resize:", err)
+			continue
+		}
+		job.Image = img
 		fmt.Printf("Resize stage took %v\n", time.Since(start))
 
 		start = time.Now()
-		job.Image = imageprocessing.Grayscale(job.Image)
+		img, err = safeGrayscale(job.Image)
+		if err != nil {
+			fmt.Println("grayscale:", err)
+			continue
+		}
+		job.Image = img
 		fmt.Printf("Grayscale stage took %v\n", time.Since(start))
 
 		start = time.Now()
-		imageprocessing.WriteImage(job.OutPath, job.Image)
+		if err := os.MkdirAll(filepath.Dir(job.OutPath), 0o755); err != nil {
+			fmt.Println("save:", err)
+			continue
+		}
+		if err := safeWriteImage(job.OutPath, job.Image); err != nil {
+			fmt.Println("save:", err)
+			continue
+		}
 		fmt.Printf("Save stage took %v\n", time.Since(start))
 
 		fmt.Println("Job completed successfully!")
@@ -75,107 +209,341 @@ func runSequential(imagePaths []string, outputDir string) {
 	fmt.Printf("Total sequential pipeline time: %v\n", totalElapsed)
 }
 
-// Parallel implementation of pipeline stages
-func runParallel(imagePaths []string, outputDir string) {
+// Summary aggregates the JobResults a parallel run produced: how many jobs
+// made it through, how many failed, and the total time spent in each stage
+// across all jobs.
+type Summary struct {
+	Successes int
+	Failures  int
+	PerStage  map[string]time.Duration
+	Total     time.Duration
+}
+
+// Parallel implementation of pipeline stages. The sequence of processing
+// steps comes from a pipeline.Config instead of being hard-wired, so
+// runParallel wires one goroutine-stage per configured step. workers gives
+// a worker-pool size per stage, keyed by "load", each step's Name, and
+// "save"; a stage not present in workers runs with a single goroutine, the
+// original behavior.
+// runParallel also takes a context.Context so a SIGINT or caller-imposed
+// timeout tears every stage down: each selects on ctx.Done() both when
+// reading its input and when forwarding a result, so a consumer that stops
+// can't leave an upstream writer blocked forever.
+func runParallel(ctx context.Context, jobs <-chan Job, outputDir string, steps []pipeline.Step, stepNames []string, output pipeline.OutputConfig, workers map[string]int, addressing Addressing, manifest *manifestWriter) Summary {
 	startPipeline := time.Now() // Start timer for parallel mode
 
-	channel1 := loadImage(imagePaths, outputDir)
-	channel2 := resize(channel1)
-	channel3 := convertToGrayscale(channel2)
-	writeResults := saveImage(channel3)
+	saveWorkers := workerCount(workers, "save")
+	results := make(chan JobResult, saveWorkers)
+
+	stage := loadImage(ctx, jobs, outputDir, workerCount(workers, "load"), results)
+	for i, step := range steps {
+		stage = applyStep(ctx, stage, step, stepNames[i], workerCount(workers, stepNames[i]))
+	}
+	saveImage(ctx, stage, outputDir, output, addressing, manifest, saveWorkers, results)
 
-	// Wait for pipeline to complete
-	for success := range writeResults {
-		if success {
-			fmt.Println("Success!")
+	summary := Summary{PerStage: map[string]time.Duration{}}
+	for res := range results {
+		if res.Err != nil {
+			summary.Failures++
+			fmt.Printf("Failed: %s (stage %s): %v\n", res.Path, res.Stage, res.Err)
 		} else {
-			fmt.Println("Failed!")
+			summary.Successes++
+			fmt.Printf("Success: %s\n", res.Path)
+		}
+		for stage, d := range res.Durations {
+			summary.PerStage[stage] += d
 		}
 	}
 
-	totalElapsed := time.Since(startPipeline)
-	fmt.Printf("Total parallel pipeline time: %v\n", totalElapsed)
+	summary.Total = time.Since(startPipeline)
+	fmt.Printf("Total parallel pipeline time: %v (successes=%d failures=%d)\n", summary.Total, summary.Successes, summary.Failures)
+	return summary
 }
 
-func loadImage(paths []string, outputDir string) <-chan Job {
-	out := make(chan Job)
-	go func() {
-		for _, p := range paths {
-			if err := validateImage(p); err != nil {
-				fmt.Println(err)
-				continue
-			}
-			job := Job{InputPath: p, OutPath: strings.Replace(p, "images/", outputDir, 1)}
-			job.Image = imageprocessing.ReadImage(p)
-			out <- job
+// workerCount looks up how many workers a stage should run with, defaulting
+// to 1 (the original single-goroutine-per-stage behavior).
+func workerCount(workers map[string]int, stage string) int {
+	if n, ok := workers[stage]; ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// stepNames returns the configured name for each step in cfg, falling back
+// to "step-N" for unnamed steps so it can key a workers map.
+func stepNames(cfg pipeline.Config) []string {
+	names := make([]string, len(cfg.Steps))
+	for i, sc := range cfg.Steps {
+		if sc.Name != "" {
+			names[i] = sc.Name
+		} else {
+			names[i] = fmt.Sprintf("step-%d", i)
 		}
-		close(out)
-	}()
-	return out
+	}
+	return names
 }
 
-func resize(input <-chan Job) <-chan Job {
-	out := make(chan Job)
+// fanWorkers runs n copies of worker, all reading from input and
+// multiplexing their results onto a single buffered output channel. worker
+// returns ok=false to drop a job instead of forwarding it. Each worker
+// selects on ctx.Done() both when pulling from input and when forwarding a
+// result, so cancellation can't leave it blocked on either side. out is
+// closed, via a WaitGroup-guarded closer goroutine, once every worker has
+// stopped.
+func fanWorkers(ctx context.Context, n int, input <-chan Job, worker func(Job) (Job, bool)) <-chan Job {
+	out := make(chan Job, n)
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job, ok := <-input:
+					if !ok {
+						return
+					}
+					result, ok := worker(job)
+					if !ok {
+						continue
+					}
+					select {
+					case out <- result:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
 	go func() {
-		for job := range input {
-			start := time.Now()
-			job.Image = imageprocessing.Resize(job.Image)
-			fmt.Printf("Resize stage took %v\n", time.Since(start))
-			out <- job
-		}
+		wg.Wait()
 		close(out)
 	}()
+
 	return out
 }
 
-func convertToGrayscale(input <-chan Job) <-chan Job {
-	out := make(chan Job)
-	go func() {
-		for job := range input {
-			start := time.Now()
-			job.Image = imageprocessing.Grayscale(job.Image)
-			fmt.Printf("Grayscale stage took %v\n", time.Since(start))
-			out <- job
+// loadImage is the first real stage after the walker: it validates and
+// reads each file, and points every Job at results so later stages can
+// report through it without needing their own channel.
+func loadImage(ctx context.Context, jobs <-chan Job, outputDir string, workers int, results chan<- JobResult) <-chan Job {
+	return fanWorkers(ctx, workers, jobs, func(job Job) (Job, bool) {
+		job.Result = results
+
+		if err := validateImage(job.InputPath); err != nil {
+			job.fail("load", err)
+			return job, true
 		}
-		close(out)
-	}()
-	return out
+
+		outPath, err := relativeOutputPath(job, outputDir)
+		if err != nil {
+			job.fail("load", err)
+			return job, true
+		}
+		job.OutPath = outPath
+
+		start := time.Now()
+		img, err := safeReadImage(job.InputPath)
+		if err != nil {
+			job.fail("load", err)
+			return job, true
+		}
+		job.Image = img
+		job.recordDuration("load", time.Since(start))
+		return job, true
+	})
 }
 
-func saveImage(input <-chan Job) <-chan bool {
-	out := make(chan bool)
-	go func() {
-		for job := range input {
-			start := time.Now()
-			imageprocessing.WriteImage(job.OutPath, job.Image)
-			fmt.Printf("Save stage took %v\n", time.Since(start))
-			out <- true
+// applyStep wires a single configured pipeline.Step into the channel chain.
+// A Job that already failed upstream is forwarded untouched so saveImage
+// can still report it.
+func applyStep(ctx context.Context, input <-chan Job, step pipeline.Step, name string, workers int) <-chan Job {
+	return fanWorkers(ctx, workers, input, func(job Job) (Job, bool) {
+		if job.Err != nil {
+			return job, true
 		}
-		close(out)
+
+		start := time.Now()
+		img, err := step.Apply(job.Image)
+		if err != nil {
+			job.fail(name, err)
+			return job, true
+		}
+		job.Image = img
+		job.recordDuration(name, time.Since(start))
+		return job, true
+	})
+}
+
+// processSave writes a single Job to disk (or, if it already failed
+// upstream, skips straight to reporting that failure) and returns the
+// JobResult it produced.
+func processSave(job Job, outputDir string, output pipeline.OutputConfig, addressing Addressing, manifest *manifestWriter) JobResult {
+	if job.Err != nil {
+		return JobResult{Path: job.InputPath, Err: job.Err, Stage: job.Stage, Durations: job.Durations}
+	}
+
+	start := time.Now()
+	outPath, hash, err := writeJobImage(job, outputDir, output, addressing)
+	if err != nil {
+		job.fail("save", err)
+		return JobResult{Path: job.InputPath, Err: job.Err, Stage: job.Stage, Durations: job.Durations}
+	}
+	if manifest != nil {
+		if err := manifest.record(job.InputPath, hash); err != nil {
+			fmt.Println("manifest:", err)
+		}
+	}
+	job.recordDuration("save", time.Since(start))
+	return JobResult{Path: outPath, Durations: job.Durations}
+}
+
+// saveImage is the terminus of the parallel pipeline: it writes whatever
+// jobs got this far - under addressing, optionally recording a manifest
+// line per hashed output - and reports every Job's outcome on job.Result.
+// Like fanWorkers, each worker selects on ctx.Done() on both sides so a
+// canceled run can't block forever; results is closed once every worker has
+// stopped.
+func saveImage(ctx context.Context, input <-chan Job, outputDir string, output pipeline.OutputConfig, addressing Addressing, manifest *manifestWriter, workers int, results chan<- JobResult) {
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job, ok := <-input:
+					if !ok {
+						return
+					}
+					result := processSave(job, outputDir, output, addressing, manifest)
+					select {
+					case job.Result <- result:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
 	}()
-	return out
+}
+
+// defaultPipelineConfig reproduces the pipeline's original Resize->Grayscale
+// behavior for callers that don't pass -pipeline.
+func defaultPipelineConfig() pipeline.Config {
+	return pipeline.Config{
+		Steps: []pipeline.StepConfig{
+			{Name: "resize", Type: pipeline.StepResize, Options: map[string]interface{}{"width": 800.0}},
+			{Name: "grayscale", Type: pipeline.StepGrayscale},
+		},
+		Output: pipeline.OutputConfig{Format: "jpeg", Quality: 90},
+	}
 }
 
 func main() {
+	pipelinePath := flag.String("pipeline", "", "path to a pipeline JSON config (defaults to a built-in resize+grayscale pipeline)")
+	workersFlag := flag.String("workers", "", `JSON object of per-stage worker counts, e.g. {"load":2,"resize":4,"save":8} (unlisted stages default to 1)`)
+	addressingFlag := flag.String("addressing", "path", `output addressing scheme: "path" (mirror the input layout) or "hash" (content-addressed, idempotent store)`)
+	flag.Parse()
+
 	outputDir := "./images/output/"
 	if !directoryExists(outputDir) {
 		fmt.Printf("Output directory does not exist: %s\n", outputDir)
 		return
 	}
 
-	imagePaths := []string{"images/watermelon.jpg", "images/apple.jpg", "images/blueberry.jpg", "images/lemon.jpg"}
+	roots := flag.Args()
+	if len(roots) == 0 {
+		roots = []string{"images"}
+	}
+
+	cfg := defaultPipelineConfig()
+	if *pipelinePath != "" {
+		loaded, err := pipeline.Load(*pipelinePath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		cfg = loaded
+	}
+
+	steps, err := pipeline.Build(cfg)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	workers := map[string]int{}
+	if *workersFlag != "" {
+		if err := json.Unmarshal([]byte(*workersFlag), &workers); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
 
 	var mode string
 	fmt.Println("Enter mode (sequential/parallel):")
 	fmt.Scanln(&mode)
+	if mode != "sequential" && mode != "parallel" {
+		fmt.Println("Invalid mode. Please enter 'sequential' or 'parallel'.")
+		return
+	}
+
+	// runSequential predates the configurable pipeline subpackage and still
+	// only ever runs the hard-coded resize+grayscale steps, so pipeline-only
+	// flags would silently be ignored under sequential mode. Reject this
+	// before touching the filesystem below (hash buckets, manifest file).
+	if mode == "sequential" && (*pipelinePath != "" || *workersFlag != "" || *addressingFlag != "path") {
+		fmt.Println("Sequential mode always runs the built-in resize+grayscale pipeline; -pipeline, -workers, and -addressing require parallel mode.")
+		return
+	}
+
+	addressing, err := parseAddressing(*addressingFlag)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var manifest *manifestWriter
+	if addressing == AddressByHash {
+		if err := createHashBuckets(outputDir); err != nil {
+			fmt.Println(err)
+			return
+		}
+		manifest, err = newManifestWriter(outputDir)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer manifest.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	jobs, walkResults := walkImages(roots, nil, ctx.Done())
 
 	if mode == "sequential" {
 		fmt.Println("Running pipeline in sequential mode...")
-		runSequential(imagePaths, outputDir)
-	} else if mode == "parallel" {
-		fmt.Println("Running pipeline in parallel mode...")
-		runParallel(imagePaths, outputDir)
+		runSequential(ctx, jobs, outputDir)
 	} else {
-		fmt.Println("Invalid mode. Please enter 'sequential' or 'parallel'.")
+		fmt.Println("Running pipeline in parallel mode...")
+		runParallel(ctx, jobs, outputDir, steps, stepNames(cfg), cfg.Output, workers, addressing, manifest)
+	}
+
+	if res := <-walkResults; res.Err != nil {
+		fmt.Printf("walk error: %v\n", res.Err)
 	}
 }
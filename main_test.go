@@ -1,120 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
-	"strings"
+	"time"
 	"image"
 	"image/color"
+	"image/jpeg"
 	"github.com/stretchr/testify/assert"
-	"goroutines_pipeline/image_processing"
+	"goroutines_pipeline/pipeline"
 )
 
-// Mock image processing functions for testing
-
-// MockReadImage simulates reading an image (just returns a red 1x1 image).
+// mockReadImage returns a tiny in-memory image for tests that need a Job's
+// Image populated without round-tripping through the real image_processing
+// package.
 func mockReadImage(path string) image.Image {
-	// Create a simple 1x1 image for testing
 	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
 	img.Set(0, 0, color.RGBA{255, 0, 0, 255}) // Red pixel
 	return img
 }
 
-// MockWriteImage simulates writing an image (no-op for testing).
-func mockWriteImage(outPath string, img image.Image) {
-	// Print the output path for testing
-	fmt.Println("Mock writing image to", outPath)
-}
-
-// Replace the original ReadImage and WriteImage functions with mocks for testing.
-var ReadImage = mockReadImage
-var WriteImage = mockWriteImage
-
-// Error checking: Validate image file (ensure it's a JPEG)
-func mockValidateImage(filePath string) error {
-	// Check if the file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("file does not exist: %s", filePath)
-	}
-
-	// Check if the file has a valid JPEG extension
-	if !strings.HasSuffix(filePath, ".jpeg") && !strings.HasSuffix(filePath, ".jpg") {
-		return fmt.Errorf("invalid file type: %s. Only .jpeg and .jpg files are allowed", filePath)
-	}
-
-	return nil
-}
-
-// Output error checking: Function to check if a directory exists
-func mockDirectoryExists(path string) bool {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return info.IsDir()
-}
-
-func mockLoadImage(paths []string, outputDir string) <-chan Job {
-	out := make(chan Job)
-	go func() {
-		for _, p := range paths {
-			// Validate the image file before processing
-			if err := validateImage(p); err != nil {
-				fmt.Println(err)
-				continue // Skip the file if it's invalid
-			}
-			job := Job{
-				InputPath: p,
-				OutPath:   strings.Replace(p, "images/", outputDir, 1),
-				Image:     ReadImage(p), // Use the mocked ReadImage function
-			}
-			out <- job
-		}
-		close(out)
-	}()
-	return out
-}
-
-func mockResize(input <-chan Job) <-chan Job {
-	out := make(chan Job)
-	go func() {
-		for job := range input {
-			// Simulate resizing the image
-			job.Image = imageprocessing.Resize(job.Image)
-			out <- job
-		}
-		close(out)
-	}()
-	return out
-}
-
-func mockConvertToGrayscale(input <-chan Job) <-chan Job {
-	out := make(chan Job)
-	go func() {
-		for job := range input {
-			// Simulate converting the image to grayscale
-			job.Image = imageprocessing.Grayscale(job.Image)
-			out <- job
-		}
-		close(out)
-	}()
-	return out
-}
-
-func mockSaveImage(input <-chan Job) <-chan bool {
-	out := make(chan bool)
-	go func() {
-		for job := range input {
-			// Simulate saving the image
-			WriteImage(job.OutPath, job.Image) // Use the mocked WriteImage function
-			out <- true
-		}
-		close(out)
-	}()
-	return out
-}
-
 // Unit Test for validateImage function
 func TestValidateImage(t *testing.T) {
 	// Create a temporary file for testing
@@ -153,20 +61,27 @@ func TestDirectoryExists(t *testing.T) {
 
 // Unit Test for loadImage function
 func TestLoadImage(t *testing.T) {
-	// Define test input paths
-	paths := []string{
-		"images/watermelon.jpg", // Valid path
-		"invalid/path/to/image.jpg", // Invalid path
-	}
+	dir := t.TempDir()
+	validPath := filepath.Join(dir, "watermelon.jpg")
+	f, err := os.Create(validPath)
+	assert.NoError(t, err)
+	assert.NoError(t, jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil))
+	f.Close()
+
+	// Feed loadImage the Jobs a walk would have produced
+	jobs := make(chan Job, 2)
+	jobs <- Job{InputPath: validPath, Root: dir}                         // Valid path
+	jobs <- Job{InputPath: "invalid/path/to/image.jpg", Root: "invalid"} // Invalid path
+	close(jobs)
 
-	outputDir := "./images/output/"
+	outputDir := t.TempDir()
+	results := make(chan JobResult, 2)
 
-	// Mocking the loadImage behavior by directly invoking it
-	resultChan := loadImage(paths, outputDir)
+	resultChan := loadImage(context.Background(), jobs, outputDir, 2, results)
 
 	// Test the results from the loadImage function
 	for result := range resultChan {
-		if result.InputPath == "images/watermelon.jpg" {
+		if result.InputPath == validPath {
 			assert.NotNil(t, result.Image, "Expected image to be loaded")
 		} else {
 			assert.Nil(t, result.Image, "Expected invalid image path to return nil image")
@@ -174,32 +89,45 @@ func TestLoadImage(t *testing.T) {
 	}
 }
 
-// Unit Test for resize function
-func TestResize(t *testing.T) {
-	input := make(chan Job)
-	go func() {
-		// Create a mock job with a simple image
-		job := Job{
-			InputPath: "test.jpg",
-			Image:     mockReadImage("test.jpg"),
-			OutPath:   "./output/test_resized.jpg",
-		}
-		input <- job
-		close(input)
-	}()
+// Unit Test for walkImages function
+func TestWalkImages(t *testing.T) {
+	dir, err := os.MkdirTemp("", "test-walk")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
 
-	output := resize(input)
+	for _, name := range []string{"a.jpg", "b.jpeg", "c.txt"} {
+		f, err := os.Create(dir + "/" + name)
+		assert.NoError(t, err)
+		f.Close()
+	}
 
-	for job := range output {
-		assert.NotNil(t, job.Image, "Expected image to be resized")
+	done := make(chan struct{})
+	defer close(done)
+
+	jobs, results := walkImages([]string{dir}, nil, done)
+
+	var found []string
+	for job := range jobs {
+		found = append(found, job.InputPath)
 	}
+	assert.Len(t, found, 2, "Expected only .jpg/.jpeg files to be walked")
+
+	res := <-results
+	assert.NoError(t, res.Err)
 }
 
-// Unit Test for convertToGrayscale function
-func TestConvertToGrayscale(t *testing.T) {
+// Unit Test for applyStep, wiring a configured pipeline.Step into a Job channel
+func TestApplyStep(t *testing.T) {
+	steps, err := pipeline.Build(pipeline.Config{
+		Steps: []pipeline.StepConfig{
+			{Name: "grayscale", Type: pipeline.StepGrayscale},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, steps, 1)
+
 	input := make(chan Job)
 	go func() {
-		// Create a mock job with a simple image
 		job := Job{
 			InputPath: "test.jpg",
 			Image:     mockReadImage("test.jpg"),
@@ -209,14 +137,115 @@ func TestConvertToGrayscale(t *testing.T) {
 		close(input)
 	}()
 
-	output := convertToGrayscale(input)
+	output := applyStep(context.Background(), input, steps[0], "grayscale", 1)
 
 	for job := range output {
-		// Assert the image has been converted (mocking the process here)
-		assert.NotNil(t, job.Image, "Expected image to be converted to grayscale")
+		assert.NotNil(t, job.Image, "Expected image to be processed by the step")
 	}
 }
 
+// Unit Test for context cancellation: fanWorkers must stop and close its
+// output channel promptly once ctx is canceled, even with its input
+// channel left open and unread - the case that otherwise leaks a blocked
+// writer goroutine forever.
+func TestFanWorkersCancellation(t *testing.T) {
+	input := make(chan Job) // never closed or written to
+	ctx, cancel := context.WithCancel(context.Background())
+
+	out := fanWorkers(ctx, 2, input, func(job Job) (Job, bool) {
+		return job, true
+	})
+
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		assert.False(t, ok, "Expected the output channel to close after cancellation")
+	case <-time.After(time.Second):
+		t.Fatal("fanWorkers did not close its output channel after ctx was canceled")
+	}
+}
+
+// Unit Test for pipeline.Build rejecting an unknown step type
+func TestPipelineBuildUnknownStep(t *testing.T) {
+	_, err := pipeline.Build(pipeline.Config{
+		Steps: []pipeline.StepConfig{
+			{Name: "mystery", Type: pipeline.StepType(99)},
+		},
+	})
+	assert.Error(t, err)
+}
+
+// Unit Test for hash addressing: writing the same image twice must produce
+// the same content-addressed path, and the bucket directories must exist.
+func TestWriteJobImageHashAddressing(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, createHashBuckets(dir))
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{1, 2, 3, 255})
+	job := Job{InputPath: "images/a.jpg", Image: img}
+	output := pipeline.OutputConfig{Format: "jpeg", Quality: 90}
+
+	path1, hash1, err := writeJobImage(job, dir, output, AddressByHash)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, hash1)
+
+	path2, hash2, err := writeJobImage(job, dir, output, AddressByHash)
+	assert.NoError(t, err)
+	assert.Equal(t, path1, path2, "Expected the same image to hash to the same output path")
+	assert.Equal(t, hash1, hash2)
+
+	_, err = os.Stat(filepath.Join(dir, hash1[:2]))
+	assert.NoError(t, err, "Expected the hash's bucket directory to exist")
+}
+
+// benchJobs writes n tiny real JPEGs to dir and returns a Job channel
+// seeded with their paths, the shape loadImage expects from walkImages.
+func benchJobs(b *testing.B, dir string, n int) <-chan Job {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	jobs := make(chan Job, n)
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("bench-%d.jpg", i))
+		f, err := os.Create(path)
+		assert.NoError(b, err)
+		assert.NoError(b, jpeg.Encode(f, img, nil))
+		f.Close()
+		jobs <- Job{InputPath: path, Root: dir}
+	}
+	close(jobs)
+	return jobs
+}
+
+// BenchmarkRunParallel compares the original single-goroutine-per-stage
+// throughput against a pooled-worker configuration on a batch of images.
+func BenchmarkRunParallel(b *testing.B) {
+	const batchSize = 50
+
+	steps, err := pipeline.Build(pipeline.Config{
+		Steps: []pipeline.StepConfig{{Name: "grayscale", Type: pipeline.StepGrayscale}},
+	})
+	assert.NoError(b, err)
+	output := pipeline.OutputConfig{Format: "jpeg", Quality: 90}
+
+	run := func(b *testing.B, workers map[string]int) {
+		srcDir := b.TempDir()
+		outDir := b.TempDir() + "/"
+		for i := 0; i < b.N; i++ {
+			jobs := benchJobs(b, srcDir, batchSize)
+			runParallel(context.Background(), jobs, outDir, steps, []string{"grayscale"}, output, workers, AddressByPath, nil)
+		}
+	}
+
+	b.Run("1-worker-per-stage", func(b *testing.B) {
+		run(b, map[string]int{"load": 1, "grayscale": 1, "save": 1})
+	})
+
+	b.Run("pooled-workers", func(b *testing.B) {
+		run(b, map[string]int{"load": 4, "grayscale": 4, "save": 8})
+	})
+}
+
 
 
 
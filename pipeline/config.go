@@ -0,0 +1,59 @@
+// Package pipeline loads a JSON-described sequence of image operations and
+// turns it into a list of Steps the runner can chain together at runtime,
+// instead of hard-wiring Resize->Grayscale into the binary.
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// StepType identifies which operation a StepConfig builds.
+type StepType int
+
+const (
+	StepResize StepType = iota
+	StepRotate
+	StepFlip
+	StepGrayscale
+	StepCrop
+	StepBlur
+	StepBinarize
+)
+
+// StepConfig describes one stage of the pipeline as it appears in the JSON
+// config: a human-readable name, the operation Type, and a free-form
+// Options bag whose keys depend on Type (e.g. "width"/"height" for resize).
+type StepConfig struct {
+	Name    string                 `json:"name"`
+	Type    StepType               `json:"type"`
+	Options map[string]interface{} `json:"options"`
+}
+
+// OutputConfig controls how the final image in the pipeline is encoded.
+type OutputConfig struct {
+	Format  string `json:"format"`
+	Quality int    `json:"quality"`
+}
+
+// Config is the top-level shape of a pipeline JSON file: an ordered list of
+// steps plus how to encode the result.
+type Config struct {
+	Steps  []StepConfig `json:"steps"`
+	Output OutputConfig `json:"output"`
+}
+
+// Load reads and parses a pipeline Config from path.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("pipeline: reading config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("pipeline: parsing config %s: %w", path, err)
+	}
+	return cfg, nil
+}
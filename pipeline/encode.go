@@ -0,0 +1,27 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Encode writes img to w using the format and quality described by out.
+// An empty out.Format defaults to JPEG.
+func Encode(w io.Writer, img image.Image, out OutputConfig) error {
+	switch strings.ToLower(out.Format) {
+	case "", "jpeg", "jpg":
+		quality := out.Quality
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		return png.Encode(w, img)
+	default:
+		return fmt.Errorf("pipeline: unsupported output format %q", out.Format)
+	}
+}
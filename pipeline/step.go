@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+)
+
+// Step is one operation in an image pipeline.
+type Step interface {
+	Apply(img image.Image) (image.Image, error)
+}
+
+type builderFunc func(options map[string]interface{}) (Step, error)
+
+var builders = map[StepType]builderFunc{
+	StepResize:    buildResize,
+	StepRotate:    buildRotate,
+	StepFlip:      buildFlip,
+	StepGrayscale: buildGrayscale,
+	StepCrop:      buildCrop,
+	StepBlur:      buildBlur,
+	StepBinarize:  buildBinarize,
+}
+
+// Build turns a Config's StepConfigs into concrete Steps, in order.
+func Build(cfg Config) ([]Step, error) {
+	steps := make([]Step, 0, len(cfg.Steps))
+	for _, sc := range cfg.Steps {
+		build, ok := builders[sc.Type]
+		if !ok {
+			return nil, fmt.Errorf("pipeline: step %q: unknown type %d", sc.Name, sc.Type)
+		}
+
+		step, err := build(sc.Options)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: step %q: %w", sc.Name, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func optInt(options map[string]interface{}, key string, def int) int {
+	if v, ok := options[key]; ok {
+		if f, ok := v.(float64); ok {
+			return int(f)
+		}
+	}
+	return def
+}
+
+func optFloat(options map[string]interface{}, key string, def float64) float64 {
+	if v, ok := options[key]; ok {
+		if f, ok := v.(float64); ok {
+			return f
+		}
+	}
+	return def
+}
+
+func optString(options map[string]interface{}, key, def string) string {
+	if v, ok := options[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return def
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Unit Test for Build wiring a binarize step that can run end to end,
+// producing a black-and-white image for an OCR-style Resize->Grayscale->
+// Binarize chain.
+func TestBuildBinarizeStep(t *testing.T) {
+	steps, err := Build(Config{
+		Steps: []StepConfig{
+			{Name: "grayscale", Type: StepGrayscale},
+			{Name: "binarize", Type: StepBinarize, Options: map[string]interface{}{"window": 9.0}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, steps, 2)
+
+	img := image.NewRGBA(image.Rect(0, 0, 6, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 200, B: 200, A: 255})
+		}
+	}
+
+	var out image.Image = img
+	for _, step := range steps {
+		out, err = step.Apply(out)
+		assert.NoError(t, err)
+	}
+
+	gray, ok := out.(*image.Gray)
+	assert.True(t, ok, "Expected the binarize step to produce a *image.Gray")
+	assert.Equal(t, uint8(255), gray.GrayAt(0, 0).Y)
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+
+	imageprocessing "goroutines_pipeline/image_processing"
+)
+
+// resizeStep scales an image to width x height, preserving aspect ratio if
+// one of the two is left at 0.
+type resizeStep struct {
+	width, height int
+}
+
+func buildResize(options map[string]interface{}) (Step, error) {
+	return resizeStep{
+		width:  optInt(options, "width", 0),
+		height: optInt(options, "height", 0),
+	}, nil
+}
+
+func (s resizeStep) Apply(img image.Image) (image.Image, error) {
+	return imaging.Resize(img, s.width, s.height, imaging.Lanczos), nil
+}
+
+// rotateStep rotates an image by angle degrees counter-clockwise.
+type rotateStep struct {
+	angle float64
+}
+
+func buildRotate(options map[string]interface{}) (Step, error) {
+	return rotateStep{angle: optFloat(options, "angle", 90)}, nil
+}
+
+func (s rotateStep) Apply(img image.Image) (image.Image, error) {
+	return imaging.Rotate(img, s.angle, image.Transparent), nil
+}
+
+// flipStep mirrors an image horizontally or vertically.
+type flipStep struct {
+	direction string
+}
+
+func buildFlip(options map[string]interface{}) (Step, error) {
+	return flipStep{direction: optString(options, "direction", "horizontal")}, nil
+}
+
+func (s flipStep) Apply(img image.Image) (image.Image, error) {
+	if s.direction == "vertical" {
+		return imaging.FlipV(img), nil
+	}
+	return imaging.FlipH(img), nil
+}
+
+// grayscaleStep desaturates an image.
+type grayscaleStep struct{}
+
+func buildGrayscale(map[string]interface{}) (Step, error) {
+	return grayscaleStep{}, nil
+}
+
+func (grayscaleStep) Apply(img image.Image) (image.Image, error) {
+	return imaging.Grayscale(img), nil
+}
+
+// cropStep crops width x height out of the center of an image.
+type cropStep struct {
+	width, height int
+}
+
+func buildCrop(options map[string]interface{}) (Step, error) {
+	return cropStep{
+		width:  optInt(options, "width", 0),
+		height: optInt(options, "height", 0),
+	}, nil
+}
+
+func (s cropStep) Apply(img image.Image) (image.Image, error) {
+	return imaging.CropCenter(img, s.width, s.height), nil
+}
+
+// blurStep applies a Gaussian blur of the given sigma.
+type blurStep struct {
+	sigma float64
+}
+
+func buildBlur(options map[string]interface{}) (Step, error) {
+	return blurStep{sigma: optFloat(options, "sigma", 1)}, nil
+}
+
+func (s blurStep) Apply(img image.Image) (image.Image, error) {
+	return imaging.Blur(img, s.sigma), nil
+}
+
+// binarizeStep applies Sauvola adaptive thresholding, turning a grayscale
+// (or color) image into a black-and-white one suitable for OCR.
+type binarizeStep struct {
+	window int
+	k, r   float64
+}
+
+func buildBinarize(options map[string]interface{}) (Step, error) {
+	return binarizeStep{
+		window: optInt(options, "window", 19),
+		k:      optFloat(options, "k", 0.3),
+		r:      optFloat(options, "r", 128),
+	}, nil
+}
+
+func (s binarizeStep) Apply(img image.Image) (image.Image, error) {
+	return imageprocessing.Binarize(img, s.window, s.k, s.r), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errWalkCanceled signals that a walk was stopped because done was closed;
+// it never escapes walkImages as a Result.Err.
+var errWalkCanceled = errors.New("walk canceled")
+
+// Result reports the outcome of a walkImages run once it has finished
+// emitting jobs.
+type Result struct {
+	Err error
+}
+
+// defaultImageFilter keeps .jpg/.jpeg files and skips hidden directories; it
+// is used whenever walkImages is called with a nil filter.
+func defaultImageFilter(path string, fi os.FileInfo) bool {
+	if fi.IsDir() {
+		return !strings.HasPrefix(fi.Name(), ".")
+	}
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".jpg") || strings.HasSuffix(lower, ".jpeg")
+}
+
+// walkImages recursively walks roots and emits a Job per file accepted by
+// filter (a nil filter falls back to defaultImageFilter). It is the head of
+// both runSequential and runParallel, replacing a hard-coded path list with
+// an arbitrary directory tree. The walk stops as soon as done is closed, and
+// the returned Result channel receives exactly one value, after jobs is
+// closed, carrying the first error encountered (if any).
+func walkImages(roots []string, filter func(path string, fi os.FileInfo) bool, done <-chan struct{}) (<-chan Job, <-chan Result) {
+	if filter == nil {
+		filter = defaultImageFilter
+	}
+
+	jobs := make(chan Job)
+	results := make(chan Result, 1)
+
+	go func() {
+		defer close(jobs)
+
+		var walkErr error
+		for _, root := range roots {
+			err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if fi.IsDir() {
+					if path != root && !filter(path, fi) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !filter(path, fi) {
+					return nil
+				}
+				select {
+				case jobs <- Job{InputPath: path, Root: root}:
+					return nil
+				case <-done:
+					return errWalkCanceled
+				}
+			})
+			if err == errWalkCanceled {
+				break
+			}
+			if err != nil {
+				walkErr = err
+				break
+			}
+		}
+		close(jobs)
+		results <- Result{Err: walkErr}
+		close(results)
+	}()
+
+	return jobs, results
+}